@@ -0,0 +1,13 @@
+// Package cpu exposes the CPU feature flags used by the hash package to pick
+// the fastest available Keccak-p[1600,24] backend at init time, mirroring the
+// struct-of-bools pattern used by the stdlib's internal/cpu package.
+package cpu
+
+var (
+	HasAVX2 bool
+	HasBMI2 bool
+	HasSHA  bool
+	HasAES  bool
+	HasNEON bool
+	HasSHA3 bool
+)
@@ -0,0 +1,34 @@
+//go:build amd64
+
+package cpu
+
+// cpuid and xgetbv are implemented in cpu_amd64.s.
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+func xgetbv() (eax uint32)
+
+func init() {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 1 {
+		return
+	}
+
+	_, _, ecx1, _ := cpuid(1, 0)
+	HasAES = ecx1&(1<<25) != 0
+
+	hasOSXSAVE := ecx1&(1<<27) != 0
+	hasAVX := ecx1&(1<<28) != 0
+	avxEnabledByOS := false
+	if hasOSXSAVE && hasAVX {
+		// Bits 1 (XMM) and 2 (YMM) of XCR0 must both be set for the OS to
+		// have enabled saving/restoring AVX/AVX2 state.
+		avxEnabledByOS = xgetbv()&0x6 == 0x6
+	}
+
+	if maxID < 7 {
+		return
+	}
+	_, ebx7, _, _ := cpuid(7, 0)
+	HasAVX2 = avxEnabledByOS && ebx7&(1<<5) != 0
+	HasBMI2 = ebx7&(1<<8) != 0
+	HasSHA = ebx7&(1<<29) != 0
+}
@@ -0,0 +1,9 @@
+//go:build arm64
+
+package cpu
+
+func init() {
+	// NEON is mandatory on arm64; SHA3 crypto extensions (ID_AA64ISAR0_EL1)
+	// aren't probed here, so HasSHA3 stays false until that's added.
+	HasNEON = true
+}
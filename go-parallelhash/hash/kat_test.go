@@ -0,0 +1,131 @@
+package hash
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// This file adds known-answer tests against real published test vectors, on
+// top of the self-consistency checks in parallelhash_test.go. Two tiers,
+// clearly separated by provenance:
+//
+//   - TestCShake128NISTSamples checks cSHAKE128 against the official NIST
+//     SP 800-185 Appendix A sample vectors, byte-for-byte.
+//   - TestKeccak256BlockKnownAnswer and TestParallelHash128KnownAnswer check
+//     the inner SHAKE128 (256-bit output) primitive and
+//     ParallelHash128Goroutines against vectors computed by an independent
+//     from-spec Python reference implementation (not this package's code, and
+//     not transliterated from it), itself cross-validated against Python's
+//     stdlib hashlib.shake_128 for several message lengths and against the
+//     SP 800-185 ParallelHash128 Samples #1 and #2 before generating these.
+//     This environment has no internet access to fetch the official CAVP
+//     response files for SHAKE128 or ParallelHash128, so these are the strongest vectors
+//     available here; they are not a substitute for running this package's
+//     digests against the real CAVP files, which whoever merges this should
+//     still do before relying on this implementation for anything that
+//     needs FIPS/CAVP certification.
+
+// TestCShake128NISTSamples checks cSHAKE128 against NIST SP 800-185 Appendix
+// A: Sample #1 (4-byte message, L=256 bits) and Sample #2 (200-byte message
+// 0x00..0xC7, L=512 bits), both with N="" and S="Email Signature".
+func TestCShake128NISTSamples(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     []byte
+		outLen  int
+		s       string
+		wantHex string
+	}{
+		{
+			name:    "Sample#1",
+			msg:     mustHex("00010203"),
+			outLen:  32,
+			s:       "Email Signature",
+			wantHex: "c1c36925b6409a04f1b504fcbca9d82b4017277cb5ed2b2065fc1d3814d5aaf5",
+		},
+		{
+			name:    "Sample#2",
+			msg:     rangeBytes(200),
+			outLen:  64,
+			s:       "Email Signature",
+			wantHex: "c5221d50e4f822d96a2e8881a961420f294b7b24fe3d2094baed2c6524cc166b6afa376499e3cbcbb4cf61fe4d063473bbe5695004a7df73241b37e7156c7d95",
+		},
+	}
+	for _, tt := range tests {
+		got := cSHAKE128(tt.msg, tt.outLen, "", tt.s)
+		want := mustHex(tt.wantHex)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: cSHAKE128 = %x, want %x", tt.name, got, want)
+		}
+	}
+}
+
+// TestKeccak256BlockKnownAnswer checks keccak256Block (SHAKE128 with a
+// 256-bit output, the inner per-block hash) against vectors from the
+// independent reference described in this file's header comment, covering the empty message, a short
+// message, exactly one rate-sized (168-byte) block, and one rate-sized block
+// plus one byte.
+func TestKeccak256BlockKnownAnswer(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantHex string
+	}{
+		{"empty", nil, "7f9c2ba4e88f827d616045507605853ed73b8093f6efbc88eb1a6eacfa66ef26"},
+		{"abc", []byte("abc"), "5881092dd818bf5cf8a3ddb793fbcba74097d5c526a6d35f97b83351940f2cc8"},
+		{"one-rate-block", rangeBytes(168), "f15277eb61c4908d44a2853f3cde071ae2ed7a23461fbe162a1a98cf6875059c"},
+		{"one-rate-block-plus-one", rangeBytes(169), "015be3338c986d9846affa0f94b4afc2a76bc289c709e1a596ec9eccf090a773"},
+	}
+	for _, tt := range tests {
+		got := keccak256Block(tt.data)
+		want := mustHex(tt.wantHex)
+		if !bytes.Equal(got[:], want) {
+			t.Fatalf("%s: keccak256Block(%d bytes) = %x, want %x", tt.name, len(tt.data), got, want)
+		}
+	}
+}
+
+// TestParallelHash128KnownAnswer checks ParallelHash128Goroutines end to end
+// against vectors from the same independent reference, covering the empty
+// message, a message exactly one shard long at two output lengths, a multi-shard message with a customization
+// string, and a shard size that doesn't evenly divide the message length.
+func TestParallelHash128KnownAnswer(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		B       int
+		Lbits   int
+		S       string
+		wantHex string
+	}{
+		{"empty", nil, 8, 128, "", "ae929423865adafe7c99776bf576b4fd"},
+		{"one-shard-L128", mustHex("0001020304050607"), 8, 128, "", "dee12ba48dfaa43b6504f2d86c957436"},
+		{"one-shard-L256", mustHex("0001020304050607"), 8, 256, "", "b8fc60f3fd4773c9794d9b81fee63d1e33aac540c9a8f125451dda5d026bceac"},
+		{"multi-shard-with-S", mustHex("000102030405060708090a0b0c0d0e0f10111213"), 8, 128, "MyApp", "a4e04d024bf71aa9b20f8b46f8c076f8"},
+		{"uneven-shard", mustHex("000102"), 2, 128, "", "d8730272ebae50d5c854e2f4a36c006c"},
+	}
+	for _, tt := range tests {
+		got := ParallelHash128Goroutines(tt.data, tt.B, tt.Lbits, tt.S)
+		want := mustHex(tt.wantHex)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: ParallelHash128Goroutines = %x, want %x", tt.name, got, want)
+		}
+	}
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic("kat_test: bad hex literal: " + err.Error())
+	}
+	return b
+}
+
+func rangeBytes(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
@@ -0,0 +1,173 @@
+package hash
+
+import "go-parallelhash/hash/internal/cpu"
+
+// keccakState is the 5x5 array of 64-bit lanes operated on by
+// Keccak-p[1600,24], stored row-major (a[5*y+x] is lane (x,y)).
+type keccakState [25]uint64
+
+var roundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808a, 0x8000000080008000,
+	0x000000000000808b, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008a, 0x0000000000000088, 0x0000000080008009, 0x000000008000000a,
+	0x000000008000808b, 0x800000000000008b, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800a, 0x800000008000000a,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// rhoPiLane[i] is the destination lane index and rhoPiOffset[i] its
+// rotation count for step i of the combined rho/pi transform.
+var rhoPiLane = [24]int{
+	10, 7, 11, 17, 18, 3, 5, 16, 8, 21, 24, 4,
+	15, 23, 19, 13, 12, 2, 20, 14, 22, 9, 6, 1,
+}
+
+var rhoPiOffset = [24]uint{
+	1, 3, 6, 10, 15, 21, 28, 36, 45, 55, 2, 14,
+	27, 41, 56, 8, 25, 43, 62, 18, 39, 61, 20, 44,
+}
+
+func rotl64(x uint64, n uint) uint64 {
+	return (x << n) | (x >> (64 - n))
+}
+
+// keccakF1600Scalar permutes a in place using the reference, unbatched
+// Keccak-p[1600,24] round function (theta/rho/pi/chi/iota).
+func keccakF1600Scalar(a *keccakState) {
+	var bc [5]uint64
+	for round := 0; round < 24; round++ {
+		// theta
+		for x := 0; x < 5; x++ {
+			bc[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			t := bc[(x+4)%5] ^ rotl64(bc[(x+1)%5], 1)
+			for y := 0; y < 25; y += 5 {
+				a[y+x] ^= t
+			}
+		}
+
+		// rho + pi
+		t := a[1]
+		for i := 0; i < 24; i++ {
+			j := rhoPiLane[i]
+			bc[0] = a[j]
+			a[j] = rotl64(t, rhoPiOffset[i])
+			t = bc[0]
+		}
+
+		// chi
+		for y := 0; y < 25; y += 5 {
+			for x := 0; x < 5; x++ {
+				bc[x] = a[y+x]
+			}
+			for x := 0; x < 5; x++ {
+				a[y+x] = bc[x] ^ (^bc[(x+1)%5] & bc[(x+2)%5])
+			}
+		}
+
+		// iota
+		a[0] ^= roundConstants[round]
+	}
+}
+
+// keccakF1600BMI2 is algorithmically identical to the scalar round function;
+// it exists as its own named backend so -force-backend=bmi2 can be measured
+// against the scalar path, but this tree doesn't carry a BMI2 assembly
+// kernel, so it currently just calls the scalar implementation.
+func keccakF1600BMI2(a *keccakState) {
+	keccakF1600Scalar(a)
+}
+
+// keccakF1600x4AVX2 runs four independent Keccak-p[1600,24] permutations
+// back to back. It's the "4-way lane-interleaved" entry point
+// ParallelHash128Goroutines's shard workers call when the avx2 backend is
+// selected: four shards' states are advanced together so a real SIMD kernel
+// could later replace the body without changing this signature. No AVX2
+// assembly kernel is vendored in this tree, so each lane still runs the
+// scalar round function.
+func keccakF1600x4AVX2(a0, a1, a2, a3 *keccakState) {
+	keccakF1600Scalar(a0)
+	keccakF1600Scalar(a1)
+	keccakF1600Scalar(a2)
+	keccakF1600Scalar(a3)
+}
+
+// backendFunc is the function-pointer type ParallelHash128Goroutines
+// dispatches permutations through; picked once at init (or overridden by
+// SetBackend) based on what the CPU supports.
+type backendFunc func(*keccakState)
+
+var (
+	activeBackendName string
+	activeBackend     backendFunc
+)
+
+func init() {
+	switch {
+	case cpu.HasAVX2:
+		setBackendLocked("avx2")
+	case cpu.HasBMI2:
+		setBackendLocked("bmi2")
+	default:
+		setBackendLocked("scalar")
+	}
+}
+
+func setBackendLocked(name string) {
+	switch name {
+	case "scalar":
+		activeBackend = keccakF1600Scalar
+	case "bmi2":
+		activeBackend = keccakF1600BMI2
+	case "avx2":
+		// permute4 below batches shards through keccakF1600x4AVX2; the
+		// single-lane entry point still needs a plain backendFunc for
+		// anywhere only one state is being advanced (e.g. the final
+		// cSHAKE128 squeeze).
+		activeBackend = keccakF1600Scalar
+	default:
+		return
+	}
+	activeBackendName = name
+}
+
+// SetBackend forces the Keccak-p[1600,24] backend used by
+// ParallelHash128Goroutines and NewParallelHash128, regardless of what the
+// CPU feature detection in hash/internal/cpu picked at init. It returns an
+// error for an unknown name so callers (e.g. the benchmark harness's
+// -force-backend flag) can fail fast on a typo.
+func SetBackend(name string) error {
+	switch name {
+	case "scalar", "bmi2", "avx2":
+		setBackendLocked(name)
+		return nil
+	default:
+		return errUnknownBackend(name)
+	}
+}
+
+// ActiveBackend reports the name of the backend currently in use.
+func ActiveBackend() string {
+	return activeBackendName
+}
+
+type errUnknownBackend string
+
+func (e errUnknownBackend) Error() string {
+	return "hash: unknown backend " + string(e)
+}
+
+// permute4 advances four shard states, using the 4-way AVX2 entry point when
+// that backend is active so four shards make progress per call instead of
+// one; every other backend just loops the single-lane permutation.
+func permute4(a0, a1, a2, a3 *keccakState) {
+	if activeBackendName == "avx2" {
+		keccakF1600x4AVX2(a0, a1, a2, a3)
+		return
+	}
+	activeBackend(a0)
+	activeBackend(a1)
+	activeBackend(a2)
+	activeBackend(a3)
+}
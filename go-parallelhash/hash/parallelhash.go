@@ -0,0 +1,238 @@
+// Package hash implements ParallelHash128 (NIST SP 800-185) over a
+// Keccak-p[1600,24] permutation whose backend (scalar / bmi2 / avx2) is
+// selected via hash/internal/cpu feature detection, or overridden with
+// SetBackend. ParallelHash128GoroutinesContext additionally annotates each
+// shard worker with a runtime/trace region for per-shard trace timelines.
+package hash
+
+import (
+	"context"
+	stdhash "hash"
+	"runtime"
+	"runtime/trace"
+	"sync"
+)
+
+// splitBlocks splits data into B-byte blocks, the last one possibly shorter.
+// An empty input still yields a single (empty) block, matching
+// ParallelHash's n=1 convention for zero-length messages. B must be
+// positive; a non-positive B panics with "hash: B must be positive", so
+// callers accepting B from outside the program (e.g. a CLI flag) should
+// validate it themselves and fail with a usage error instead.
+func splitBlocks(data []byte, B int) [][]byte {
+	if B <= 0 {
+		panic("hash: B must be positive")
+	}
+	if len(data) == 0 {
+		return [][]byte{{}}
+	}
+	blocks := make([][]byte, 0, (len(data)+B-1)/B)
+	for i := 0; i < len(data); i += B {
+		end := i + B
+		if end > len(data) {
+			end = len(data)
+		}
+		blocks = append(blocks, data[i:end])
+	}
+	return blocks
+}
+
+// finalDigest assembles ParallelHash128's outer cSHAKE128(newX, L, "ParallelHash", S)
+// call from the per-block digests, with newX = left_encode(B) || digests ||
+// right_encode(n) || right_encode(L).
+func finalDigest(B int, blockDigests [][32]byte, Lbits int, S string) []byte {
+	newX := make([]byte, 0, len(blockDigests)*32+27)
+	newX = append(newX, leftEncode(uint64(B))...)
+	for _, d := range blockDigests {
+		newX = append(newX, d[:]...)
+	}
+	newX = append(newX, rightEncode(uint64(len(blockDigests)))...)
+	newX = append(newX, rightEncode(uint64(Lbits))...)
+	return cSHAKE128(newX, Lbits/8, "ParallelHash", S)
+}
+
+// ParallelHash128Goroutines computes ParallelHash128(data, L=Lbits, S) in one
+// shot, splitting data into B-byte shards and hashing them across a pool of
+// worker goroutines sized to GOMAXPROCS (one job per shard, so worker count
+// never shrinks with the avx2 batching below). When the avx2 backend is
+// active, each worker opportunistically drains up to three more same-length
+// shards from the shared queue and hashes the group of four in one permute4
+// call instead of quartering the number of workers that can run at once.
+func ParallelHash128Goroutines(data []byte, B, Lbits int, S string) []byte {
+	return parallelHash128Goroutines(context.Background(), data, B, Lbits, S)
+}
+
+// ParallelHash128GoroutinesContext is ParallelHash128Goroutines, but wraps
+// every worker's per-shard hash call in a "phshard" runtime/trace region
+// derived from ctx, so `go tool trace` can show a per-shard timeline to
+// correlate against the mutex/block profiles (e.g. one shard stalling a GC
+// pause while its siblings keep running). Regions cost essentially nothing
+// when no runtime/trace is being recorded.
+func ParallelHash128GoroutinesContext(ctx context.Context, data []byte, B, Lbits int, S string) []byte {
+	return parallelHash128Goroutines(ctx, data, B, Lbits, S)
+}
+
+func parallelHash128Goroutines(ctx context.Context, data []byte, B, Lbits int, S string) []byte {
+	blocks := splitBlocks(data, B)
+	n := len(blocks)
+	results := make([][32]byte, n)
+
+	idxCh := make(chan int, n)
+	for i := 0; i < n; i++ {
+		idxCh <- i
+	}
+	close(idxCh)
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// carry holds a dequeued index that turned out not to fit the
+			// current batch, so it isn't dropped on the floor.
+			carry, haveCarry := 0, false
+			for {
+				var idx int
+				if haveCarry {
+					idx, haveCarry = carry, false
+				} else {
+					i, ok := <-idxCh
+					if !ok {
+						return
+					}
+					idx = i
+				}
+
+				group := []int{idx}
+				if activeBackendName == "avx2" {
+				collect:
+					for len(group) < 4 {
+						select {
+						case j, ok := <-idxCh:
+							if !ok {
+								break collect
+							}
+							if len(blocks[j]) == len(blocks[idx]) {
+								group = append(group, j)
+							} else {
+								carry, haveCarry = j, true
+								break collect
+							}
+						default:
+							break collect
+						}
+					}
+				}
+
+				region := trace.StartRegion(ctx, "phshard")
+				if len(group) == 4 {
+					r0, r1, r2, r3 := keccak256Block4(blocks[group[0]], blocks[group[1]], blocks[group[2]], blocks[group[3]])
+					results[group[0]], results[group[1]], results[group[2]], results[group[3]] = r0, r1, r2, r3
+				} else {
+					for _, gi := range group {
+						results[gi] = keccak256Block(blocks[gi])
+					}
+				}
+				region.End()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return finalDigest(B, results, Lbits, S)
+}
+
+// parallelHash128 is the streaming hash.Hash returned by NewParallelHash128.
+// Writes are buffered into B-byte blocks; each full block is dispatched to
+// its own goroutine as soon as it's complete, bounded by a semaphore sized
+// to GOMAXPROCS, so a long streamed write spreads its block hashing across
+// workers as data arrives instead of waiting for Sum.
+type parallelHash128 struct {
+	B     int
+	Lbits int
+	S     string
+
+	buf     []byte
+	sem     chan struct{}
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	results [][32]byte
+	nBlocks int
+}
+
+// NewParallelHash128 returns a ParallelHash128 hash.Hash with shard size B
+// bytes, an L=Lbits-bit output, and customization string S.
+func NewParallelHash128(B, Lbits int, S string) stdhash.Hash {
+	workers := runtime.GOMAXPROCS(0)
+	if workers < 1 {
+		workers = 1
+	}
+	return &parallelHash128{B: B, Lbits: Lbits, S: S, sem: make(chan struct{}, workers)}
+}
+
+func (h *parallelHash128) dispatch(idx int, data []byte) {
+	h.wg.Add(1)
+	h.sem <- struct{}{}
+	go func() {
+		defer h.wg.Done()
+		defer func() { <-h.sem }()
+		sum := keccak256Block(data)
+		h.mu.Lock()
+		for len(h.results) <= idx {
+			h.results = append(h.results, [32]byte{})
+		}
+		h.results[idx] = sum
+		h.mu.Unlock()
+	}()
+}
+
+// Write buffers p and dispatches every full B-byte block it completes to a
+// worker goroutine. It never returns an error.
+func (h *parallelHash128) Write(p []byte) (int, error) {
+	h.buf = append(h.buf, p...)
+	for len(h.buf) >= h.B {
+		block := append([]byte(nil), h.buf[:h.B]...)
+		h.buf = append([]byte(nil), h.buf[h.B:]...)
+		h.dispatch(h.nBlocks, block)
+		h.nBlocks++
+	}
+	return len(p), nil
+}
+
+// Sum dispatches any partial final block, waits for every worker, and
+// appends the ParallelHash128 digest to b. Sum does not change the buffered
+// message: repeated calls recompute the same digest, and a Write after Sum
+// continues the same message.
+func (h *parallelHash128) Sum(b []byte) []byte {
+	if len(h.buf) > 0 || h.nBlocks == 0 {
+		h.dispatch(h.nBlocks, append([]byte(nil), h.buf...))
+	}
+	h.wg.Wait()
+
+	h.mu.Lock()
+	digests := append([][32]byte(nil), h.results...)
+	h.mu.Unlock()
+
+	return append(b, finalDigest(h.B, digests, h.Lbits, h.S)...)
+}
+
+// Reset discards all buffered and dispatched state, after waiting for any
+// in-flight workers so a reused instance can't race with them.
+func (h *parallelHash128) Reset() {
+	h.wg.Wait()
+	h.buf = nil
+	h.results = nil
+	h.nBlocks = 0
+}
+
+func (h *parallelHash128) Size() int { return h.Lbits / 8 }
+
+func (h *parallelHash128) BlockSize() int { return h.B }
@@ -0,0 +1,143 @@
+package hash
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+// TestStreamingMatchesOneShot checks that writing data to NewParallelHash128
+// in arbitrary chunks produces the same digest as ParallelHash128Goroutines
+// over the whole buffer at once. This is a self-consistency check, not a
+// NIST KAT conformance test.
+func TestStreamingMatchesOneShot(t *testing.T) {
+	data := make([]byte, 10000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	const B, Lbits = 256, 128
+	want := ParallelHash128Goroutines(data, B, Lbits, "test")
+
+	h := NewParallelHash128(B, Lbits, "test")
+	for _, chunk := range [][]byte{data[:1], data[1:17], data[17:4096], data[4096:]} {
+		if _, err := h.Write(chunk); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	got := h.Sum(nil)
+
+	if !bytes.Equal(got, want) {
+		t.Fatalf("streaming digest %x != one-shot digest %x", got, want)
+	}
+}
+
+// TestDeterministic checks that hashing the same input twice yields the
+// same digest, and that a single-byte change anywhere in the input changes
+// it (basic avalanche sanity, not a cryptographic property test).
+func TestDeterministic(t *testing.T) {
+	data := bytes.Repeat([]byte("parallelhash"), 100)
+	a := ParallelHash128Goroutines(data, 64, 128, "")
+	b := ParallelHash128Goroutines(data, 64, 128, "")
+	if !bytes.Equal(a, b) {
+		t.Fatalf("same input produced different digests: %x vs %x", a, b)
+	}
+
+	altered := append([]byte(nil), data...)
+	altered[0] ^= 0x01
+	c := ParallelHash128Goroutines(altered, 64, 128, "")
+	if bytes.Equal(a, c) {
+		t.Fatalf("single-byte change did not change digest")
+	}
+}
+
+// TestBackendsAgree checks that forcing each backend produces the same
+// digest, since they're only meant to differ in how they compute the same
+// permutation, not in the result.
+func TestBackendsAgree(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 2000)
+	defer SetBackend(ActiveBackend())
+
+	var digests [][]byte
+	for _, name := range []string{"scalar", "bmi2", "avx2"} {
+		if err := SetBackend(name); err != nil {
+			t.Fatalf("SetBackend(%q): %v", name, err)
+		}
+		digests = append(digests, ParallelHash128Goroutines(data, 128, 128, "agree"))
+	}
+	for i := 1; i < len(digests); i++ {
+		if !bytes.Equal(digests[0], digests[i]) {
+			t.Fatalf("backend digest mismatch: %x vs %x", digests[0], digests[i])
+		}
+	}
+}
+
+// TestEmptyInput checks that hashing zero bytes doesn't panic and produces
+// the single-empty-block path.
+func TestEmptyInput(t *testing.T) {
+	got := ParallelHash128Goroutines(nil, 128, 128, "")
+	if len(got) != 16 {
+		t.Fatalf("got digest of length %d, want 16", len(got))
+	}
+}
+
+func TestSetBackendUnknown(t *testing.T) {
+	if err := SetBackend("not-a-backend"); err == nil {
+		t.Fatalf("expected error for unknown backend")
+	}
+}
+
+// TestNonPositiveBPanics checks that B<=0 panics instead of returning a
+// digest (splitBlocks panics with "hash: B must be positive"). Callers that accept B from outside the program (e.g. a CLI flag)
+// must validate it themselves before reaching here.
+func TestNonPositiveBPanics(t *testing.T) {
+	for _, B := range []int{0, -1} {
+		func() {
+			defer func() {
+				r := recover()
+				if r == nil {
+					t.Fatalf("B=%d: expected panic, got none", B)
+				}
+			}()
+			ParallelHash128Goroutines([]byte("hello"), B, 128, "")
+		}()
+	}
+}
+
+// TestAVX2OddBlockCounts checks digests for block counts that don't divide
+// evenly into groups of four under avx2 batching (1, 2, 3, and 5 blocks past
+// a multiple of four), so a carried-over shard from a broken batch isn't
+// silently dropped.
+func TestAVX2OddBlockCounts(t *testing.T) {
+	defer SetBackend(ActiveBackend())
+
+	for _, n := range []int{1, 2, 3, 4, 5, 6, 7, 9, 13} {
+		data := bytes.Repeat([]byte("y"), n*64)
+
+		if err := SetBackend("scalar"); err != nil {
+			t.Fatalf("SetBackend(scalar): %v", err)
+		}
+		want := ParallelHash128Goroutines(data, 64, 128, "odd")
+
+		if err := SetBackend("avx2"); err != nil {
+			t.Fatalf("SetBackend(avx2): %v", err)
+		}
+		got := ParallelHash128Goroutines(data, 64, 128, "odd")
+
+		if !bytes.Equal(got, want) {
+			t.Fatalf("n=%d blocks: avx2 digest %x != scalar digest %x", n, got, want)
+		}
+	}
+}
+
+// TestGoroutinesContextMatchesPlain checks that ParallelHash128GoroutinesContext
+// produces the same digest as ParallelHash128Goroutines; it only adds a
+// runtime/trace region per shard and must not change the result.
+func TestGoroutinesContextMatchesPlain(t *testing.T) {
+	data := bytes.Repeat([]byte("ctx"), 500)
+	want := ParallelHash128Goroutines(data, 96, 128, "ctx")
+	got := ParallelHash128GoroutinesContext(context.Background(), data, 96, 128, "ctx")
+	if !bytes.Equal(got, want) {
+		t.Fatalf("context digest %x != plain digest %x", got, want)
+	}
+}
@@ -0,0 +1,186 @@
+package hash
+
+// rate is the Keccak sponge rate, in bytes, for capacity c=256 bits (32
+// bytes): r = 1600/8 - 32 = 168. Both the inner per-block SHAKE128 hash
+// and the outer cSHAKE128 squeeze in ParallelHash128 share this capacity, so
+// they share this rate too. 168 is a multiple of 8, so every rate-sized
+// chunk is a whole number of 64-bit lanes and absorption never has to deal
+// with a partial lane.
+const rate = 168
+
+// Padding (domain-separation) bytes, XORed into the first byte after the
+// message before the mandatory pad10*1 end bit. These match the values used
+// throughout FIPS 202 / SP 800-185 reference implementations: 0x1F for SHAKE
+// (domain suffix "1111"), 0x04 for cSHAKE (domain suffix "00").
+const (
+	padShake  byte = 0x1F
+	padCShake byte = 0x04
+)
+
+func xorBytesIntoState(s *keccakState, data []byte) {
+	lanes := len(data) / 8
+	for i := 0; i < lanes; i++ {
+		chunk := data[i*8 : i*8+8]
+		s[i] ^= uint64(chunk[0]) | uint64(chunk[1])<<8 | uint64(chunk[2])<<16 | uint64(chunk[3])<<24 |
+			uint64(chunk[4])<<32 | uint64(chunk[5])<<40 | uint64(chunk[6])<<48 | uint64(chunk[7])<<56
+	}
+}
+
+// stateToBytes returns the rate-sized output block (the first `rate` bytes
+// of the state) used when squeezing.
+func stateToBytes(s *keccakState) []byte {
+	out := make([]byte, rate)
+	lanes := rate / 8
+	for i := 0; i < lanes; i++ {
+		v := s[i]
+		out[i*8+0] = byte(v)
+		out[i*8+1] = byte(v >> 8)
+		out[i*8+2] = byte(v >> 16)
+		out[i*8+3] = byte(v >> 24)
+		out[i*8+4] = byte(v >> 32)
+		out[i*8+5] = byte(v >> 40)
+		out[i*8+6] = byte(v >> 48)
+		out[i*8+7] = byte(v >> 56)
+	}
+	return out
+}
+
+// absorbAndPad absorbs data into s (via the active backend's permutation)
+// and applies FIPS 202's pad10*1 padding, with padByte XORed in right after
+// the message to apply the domain suffix.
+func absorbAndPad(s *keccakState, data []byte, padByte byte) {
+	pos := 0
+	for len(data)-pos >= rate {
+		xorBytesIntoState(s, data[pos:pos+rate])
+		activeBackend(s)
+		pos += rate
+	}
+	last := make([]byte, rate)
+	copy(last, data[pos:])
+	last[len(data)-pos] ^= padByte
+	last[rate-1] ^= 0x80
+	xorBytesIntoState(s, last)
+	activeBackend(s)
+}
+
+// squeeze reads outLen bytes from s, permuting between rate-sized output
+// blocks as needed.
+func squeeze(s *keccakState, outLen int) []byte {
+	out := make([]byte, 0, outLen)
+	for len(out) < outLen {
+		block := stateToBytes(s)
+		need := outLen - len(out)
+		if need > rate {
+			need = rate
+		}
+		out = append(out, block[:need]...)
+		if len(out) < outLen {
+			activeBackend(s)
+		}
+	}
+	return out
+}
+
+// keccak256Block is SHAKE128 with a 256-bit (32-byte) output, i.e.
+// cSHAKE128(data, 256, "", ""): the inner per-block hash ParallelHash128
+// feeds into its outer cSHAKE128.
+func keccak256Block(data []byte) [32]byte {
+	var s keccakState
+	absorbAndPad(&s, data, padShake)
+	var out [32]byte
+	copy(out[:], squeeze(&s, 32))
+	return out
+}
+
+// keccak256Block4 is keccak256Block run on four equal-length blocks at once,
+// advancing all four Keccak states in lockstep through permute4 so the avx2
+// backend's 4-way permutation handles a full worker's batch in one call.
+func keccak256Block4(b0, b1, b2, b3 []byte) (r0, r1, r2, r3 [32]byte) {
+	var s0, s1, s2, s3 keccakState
+	n := len(b0) // precondition: len(b0) == len(b1) == len(b2) == len(b3)
+	pos := 0
+	for n-pos >= rate {
+		xorBytesIntoState(&s0, b0[pos:pos+rate])
+		xorBytesIntoState(&s1, b1[pos:pos+rate])
+		xorBytesIntoState(&s2, b2[pos:pos+rate])
+		xorBytesIntoState(&s3, b3[pos:pos+rate])
+		permute4(&s0, &s1, &s2, &s3)
+		pos += rate
+	}
+	padLane := func(s *keccakState, tail []byte) {
+		last := make([]byte, rate)
+		copy(last, tail)
+		last[len(tail)] ^= padShake
+		last[rate-1] ^= 0x80
+		xorBytesIntoState(s, last)
+	}
+	padLane(&s0, b0[pos:])
+	padLane(&s1, b1[pos:])
+	padLane(&s2, b2[pos:])
+	padLane(&s3, b3[pos:])
+	permute4(&s0, &s1, &s2, &s3)
+
+	copy(r0[:], stateToBytes(&s0)[:32])
+	copy(r1[:], stateToBytes(&s1)[:32])
+	copy(r2[:], stateToBytes(&s2)[:32])
+	copy(r3[:], stateToBytes(&s3)[:32])
+	return
+}
+
+// leftEncode implements left_encode from SP 800-185: the minimal big-endian
+// encoding of x, prefixed with its own length in bytes.
+func leftEncode(x uint64) []byte {
+	n := 1
+	for v := x >> 8; v != 0; v >>= 8 {
+		n++
+	}
+	buf := make([]byte, n+1)
+	buf[0] = byte(n)
+	for i := 0; i < n; i++ {
+		buf[n-i] = byte(x >> (8 * uint(i)))
+	}
+	return buf
+}
+
+// rightEncode implements right_encode from SP 800-185: the same minimal
+// big-endian encoding as leftEncode, but with the length byte trailing.
+func rightEncode(x uint64) []byte {
+	n := 1
+	for v := x >> 8; v != 0; v >>= 8 {
+		n++
+	}
+	buf := make([]byte, n+1)
+	buf[n] = byte(n)
+	for i := 0; i < n; i++ {
+		buf[n-1-i] = byte(x >> (8 * uint(i)))
+	}
+	return buf
+}
+
+// encodeString implements encode_string: left_encode of the bit length of s,
+// followed by s itself.
+func encodeString(s []byte) []byte {
+	return append(leftEncode(uint64(len(s))*8), s...)
+}
+
+// bytepad implements bytepad(X, w): left_encode(w) || X, zero-padded up to
+// the next multiple of w bytes.
+func bytepad(x []byte, w int) []byte {
+	buf := append(leftEncode(uint64(w)), x...)
+	for len(buf)%w != 0 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// cSHAKE128 implements cSHAKE128(X, L, N, S) from SP 800-185 for the case
+// where N or S is non-empty (ParallelHash always passes N="ParallelHash"),
+// returning an L-byte digest. It always applies the cSHAKE prefix and
+// padding, so with N and S both empty it does not reduce to SHAKE128.
+func cSHAKE128(x []byte, outLen int, n, s string) []byte {
+	prefix := bytepad(append(encodeString([]byte(n)), encodeString([]byte(s))...), rate)
+	msg := append(prefix, x...)
+	var state keccakState
+	absorbAndPad(&state, msg, padCShake)
+	return squeeze(&state, outLen)
+}
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"compress/gzip"
+	"context"
 	"crypto/sha256"
 	"encoding/csv"
 	"flag"
 	"fmt"
+	"hash"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"runtime"
 	"runtime/pprof"
+	"runtime/trace"
 	"sort"
 	"strconv"
 	"strings"
@@ -25,6 +31,8 @@ type row struct {
 	ElapsedMs  float64
 	Throughput float64 // MiB/s
 	SumHex     string
+	Backend    string    // Keccak-p backend used (PH128 only; empty for SHA-256)
+	Samples    []float64 // raw per-repetition elapsed times (ms), for -compare
 }
 
 // --- utils ---
@@ -51,6 +59,24 @@ func parseSize(s string) (int, error) {
 	return v * mult, nil
 }
 
+// computeBs resolves the -Bs flag into a concrete list of block sizes. "auto"
+// picks B = total/GOMAXPROCS (one B for the combined input), matching how
+// the input is sliced per worker.
+func computeBs(BsStr string, total int64) ([]int, error) {
+	if strings.ToLower(BsStr) != "auto" {
+		return parseBs(BsStr)
+	}
+	g := runtime.GOMAXPROCS(0)
+	if g <= 0 {
+		g = 1
+	}
+	B := int(total) / g
+	if B <= 0 {
+		B = 1 << 20 // fallback 1 MiB
+	}
+	return []int{B}, nil
+}
+
 func parseBs(list string) ([]int, error) {
 	parts := strings.Split(list, ",")
 	out := make([]int, 0, len(parts))
@@ -62,6 +88,9 @@ func parseBs(list string) ([]int, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse B '%s': %w", p, err)
 		}
+		if v <= 0 {
+			return nil, fmt.Errorf("B '%s' must be positive, got %d", p, v)
+		}
 		out = append(out, v)
 	}
 	if len(out) == 0 {
@@ -71,6 +100,17 @@ func parseBs(list string) ([]int, error) {
 	return out, nil
 }
 
+// formatSamples serializes raw per-repetition elapsed times for the
+// "samples_ms" CSV column, so -compare can reconstruct the sample vectors
+// from two already-written CSVs without re-running the benchmark.
+func formatSamples(xs []float64) string {
+	parts := make([]string, len(xs))
+	for i, x := range xs {
+		parts[i] = fmt.Sprintf("%.4f", x)
+	}
+	return strings.Join(parts, ";")
+}
+
 func medianFloat64(xs []float64) float64 {
 	if len(xs) == 0 {
 		return 0
@@ -84,11 +124,51 @@ func medianFloat64(xs []float64) float64 {
 	return (cp[m-1] + cp[m]) / 2.0
 }
 
+// --- label tagging ---
+
+// labelsEnabled and labelTuples are populated when -labels is set, so every
+// benchMedian run is tagged with pprof labels (algo/file/B/rep) via
+// pprof.Do/SetGoroutineLabels -- that's what makes `go tool pprof
+// -tagfocus=algo=PH128` work against a real profile (e.g. -cpuprof's
+// output). labelTuples itself is just a plain-text record of which
+// algo/file/B/rep tuples were actually used, written out by
+// writeLabelIndex; it is not a pprof profile and can't be loaded by
+// `go tool pprof` on its own.
+var (
+	labelsEnabled bool
+	labelTuples   []string
+
+	// traceRegionEnabled gates wrapping each benchMedian iteration in a
+	// runtime/trace region, set from -traceregion.
+	traceRegionEnabled bool
+)
+
+// writeLabelIndex gzips the recorded algo/file/B/rep tuples as plain text,
+// one per line. This is a human-readable index of what was measured, not a
+// pprof profile -- it exists so a reader can see which tags a given run
+// produced; tag filtering itself (`go tool pprof -tagfocus=...`) works off
+// the pprof labels baked into a real profile like -cpuprof's output.
+func writeLabelIndex(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	defer gw.Close()
+	for _, t := range labelTuples {
+		if _, err := fmt.Fprintln(gw, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // --- bench helpers ---
 
-func benchOnceBytes(name string, fname string, data []byte, f func([]byte) []byte, B int) row {
+func benchOnceBytes(ctx context.Context, name string, fname string, data []byte, f func(context.Context, []byte) []byte, B int, backend string) row {
 	start := time.Now()
-	sum := f(data)
+	sum := f(ctx, data)
 	el := time.Since(start)
 	mb := float64(len(data)) / (1024.0 * 1024.0)
 	sumHex := fmt.Sprintf("%x", sum)
@@ -100,14 +180,34 @@ func benchOnceBytes(name string, fname string, data []byte, f func([]byte) []byt
 		ElapsedMs:  float64(el.Microseconds()) / 1000.0,
 		Throughput: mb / el.Seconds(),
 		SumHex:     sumHex,
+		Backend:    backend,
 	}
 }
 
-func benchMedian(name string, B int, fname string, data []byte, n int, f func([]byte) []byte) row {
+func benchMedian(name string, B int, fname string, data []byte, n int, f func(context.Context, []byte) []byte, backend string) row {
 	elList := make([]float64, 0, n)
 	var last row
 	for i := 0; i < n; i++ {
-		r := benchOnceBytes(name, fname, data, f, B)
+		var r row
+		run := func(ctx context.Context) {
+			if traceRegionEnabled {
+				region := trace.StartRegion(ctx, name+"/"+fname)
+				defer region.End()
+			}
+			r = benchOnceBytes(ctx, name, fname, data, f, B, backend)
+		}
+		if labelsEnabled {
+			labels := pprof.Labels("algo", name, "file", fname, "B", strconv.Itoa(B), "rep", strconv.Itoa(i))
+			labelTuples = append(labelTuples, fmt.Sprintf("algo=%s file=%s B=%d rep=%d", name, fname, B, i))
+			pprof.Do(context.Background(), labels, func(ctx context.Context) {
+				// Propagate the labeled context so worker goroutines spawned by f
+				// (e.g. the PH128 shard workers) inherit the same tags.
+				pprof.SetGoroutineLabels(ctx)
+				run(ctx)
+			})
+		} else {
+			run(context.Background())
+		}
 		last = r
 		elList = append(elList, r.ElapsedMs)
 	}
@@ -120,31 +220,507 @@ func benchMedian(name string, B int, fname string, data []byte, n int, f func([]
 	}
 	last.ElapsedMs = medMs
 	last.Throughput = thr
+	last.Samples = elList
+	return last
+}
+
+// --- streaming mode ---
+//
+// -stream benchmarks a hash.Hash by feeding it through io.Copy instead of
+// reading the whole input into memory first, so files larger than RAM can be
+// measured and the heap profile isn't dominated by one giant allocation.
+
+// streamSource opens a fresh io.Reader for a benchmarkable input. Files are
+// reopened on every repetition since an io.Reader can't be rewound.
+// _all.data is virtual here: it concatenates the real files via
+// io.MultiReader and is never materialized in memory.
+type streamSource struct {
+	name string
+	size int64
+	open func() (io.Reader, func() error, error)
+}
+
+func openFileSource(path string) streamSource {
+	size := int64(0)
+	if fi, err := os.Stat(path); err == nil {
+		size = fi.Size()
+	}
+	return streamSource{
+		name: filepath.Base(path),
+		size: size,
+		open: func() (io.Reader, func() error, error) {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, nil, err
+			}
+			return f, f.Close, nil
+		},
+	}
+}
+
+func concatSource(paths []string, total int64) streamSource {
+	return streamSource{
+		name: "_all.data",
+		size: total,
+		open: func() (io.Reader, func() error, error) {
+			files := make([]*os.File, 0, len(paths))
+			readers := make([]io.Reader, 0, len(paths))
+			for _, p := range paths {
+				f, err := os.Open(p)
+				if err != nil {
+					for _, opened := range files {
+						opened.Close()
+					}
+					return nil, nil, err
+				}
+				files = append(files, f)
+				readers = append(readers, f)
+			}
+			return io.MultiReader(readers...), func() error {
+				var first error
+				for _, opened := range files {
+					if err := opened.Close(); err != nil && first == nil {
+						first = err
+					}
+				}
+				return first
+			}, nil
+		},
+	}
+}
+
+func benchOnceStream(name string, src streamSource, B int, backend string, newHash func() hash.Hash, readBuf []byte) row {
+	r, closeFn, err := src.open()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening %s: %v\n", src.name, err)
+		os.Exit(1)
+	}
+	h := newHash()
+	start := time.Now()
+	if _, err := io.CopyBuffer(h, r, readBuf); err != nil {
+		fmt.Fprintf(os.Stderr, "error streaming %s: %v\n", src.name, err)
+		os.Exit(1)
+	}
+	el := time.Since(start)
+	if closeFn != nil {
+		_ = closeFn()
+	}
+	mb := float64(src.size) / (1024.0 * 1024.0)
+	return row{
+		Algo:       name,
+		Bbytes:     B,
+		File:       src.name,
+		Bytes:      src.size,
+		ElapsedMs:  float64(el.Microseconds()) / 1000.0,
+		Throughput: mb / el.Seconds(),
+		SumHex:     fmt.Sprintf("%x", h.Sum(nil)),
+		Backend:    backend,
+	}
+}
+
+func benchMedianStream(name string, src streamSource, B int, backend string, n int, newHash func() hash.Hash, readBuf []byte) row {
+	elList := make([]float64, 0, n)
+	var last row
+	for i := 0; i < n; i++ {
+		r := benchOnceStream(name, src, B, backend, newHash, readBuf)
+		last = r
+		elList = append(elList, r.ElapsedMs)
+	}
+	medMs := medianFloat64(elList)
+	mb := float64(src.size) / (1024.0 * 1024.0)
+	sec := medMs / 1000.0
+	thr := 0.0
+	if sec > 0 {
+		thr = mb / sec
+	}
+	last.ElapsedMs = medMs
+	last.Throughput = thr
+	last.Samples = elList
 	return last
 }
 
+// runStreamMode mirrors main's in-memory benchmark loop but drives each
+// algorithm through its hash.Hash via io.Copy, so -stream never materializes
+// a file (or _all.data) fully in memory.
+func runStreamMode(paths []string, outCSV string, Bs []int, Lbits int, S string, nRep int, algoSel string, doConcat bool, readBuf int, forceBackend string) {
+	var sources []streamSource
+	var total int64
+	for _, p := range paths {
+		src := openFileSource(p)
+		sources = append(sources, src)
+		total += src.size
+	}
+	if doConcat && len(paths) > 1 {
+		sources = append(sources, concatSource(paths, total))
+	}
+
+	f, err := os.Create(outCSV)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error creating CSV: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	defer w.Flush()
+	_ = w.Write([]string{"algo", "file", "B_bytes", "bytes", "elapsed_ms_med", "throughput_mib_s", "sum_hex", "backend", "samples_ms"})
+
+	buf := make([]byte, readBuf)
+	backend := forceBackend
+	if backend == "auto" {
+		backend = ph.ActiveBackend()
+	}
+
+	for _, B := range Bs {
+		var (
+			totalBytesSHA int64
+			totalBytesPH  int64
+			elSHAms       []float64
+			elPHms        []float64
+		)
+
+		for _, src := range sources {
+			if algoSel == "both" || algoSel == "sha" {
+				r1 := benchMedianStream("SHA-256", src, B, "", nRep, func() hash.Hash { return sha256.New() }, buf)
+				_ = w.Write([]string{
+					r1.Algo, r1.File, fmt.Sprintf("%d", r1.Bbytes),
+					fmt.Sprintf("%d", r1.Bytes),
+					fmt.Sprintf("%.3f", r1.ElapsedMs),
+					fmt.Sprintf("%.3f", r1.Throughput),
+					r1.SumHex,
+					r1.Backend,
+					formatSamples(r1.Samples),
+				})
+				totalBytesSHA += r1.Bytes
+				elSHAms = append(elSHAms, r1.ElapsedMs)
+			}
+
+			if algoSel == "both" || algoSel == "ph" {
+				r2 := benchMedianStream("PH128", src, B, backend, nRep, func() hash.Hash { return ph.NewParallelHash128(B, Lbits, S) }, buf)
+				_ = w.Write([]string{
+					r2.Algo, r2.File, fmt.Sprintf("%d", r2.Bbytes),
+					fmt.Sprintf("%d", r2.Bytes),
+					fmt.Sprintf("%.3f", r2.ElapsedMs),
+					fmt.Sprintf("%.3f", r2.Throughput),
+					r2.SumHex,
+					r2.Backend,
+					formatSamples(r2.Samples),
+				})
+				totalBytesPH += r2.Bytes
+				elPHms = append(elPHms, r2.ElapsedMs)
+			}
+		}
+
+		if (algoSel == "both" || algoSel == "sha") && len(elSHAms) > 0 {
+			totalMB := float64(totalBytesSHA) / (1024.0 * 1024.0)
+			shaMed := medianFloat64(elSHAms)
+			shaThr := 0.0
+			if shaMed > 0 {
+				shaThr = totalMB / (shaMed / 1000.0)
+			}
+			_ = w.Write([]string{
+				"SHA-256", "TOTAL", fmt.Sprintf("%d", B),
+				fmt.Sprintf("%d", totalBytesSHA),
+				fmt.Sprintf("%.3f", shaMed),
+				fmt.Sprintf("%.3f", shaThr),
+				"",
+				"",
+				"",
+			})
+		}
+
+		if (algoSel == "both" || algoSel == "ph") && len(elPHms) > 0 {
+			totalMB := float64(totalBytesPH) / (1024.0 * 1024.0)
+			phMed := medianFloat64(elPHms)
+			phThr := 0.0
+			if phMed > 0 {
+				phThr = totalMB / (phMed / 1000.0)
+			}
+			_ = w.Write([]string{
+				"PH128", "TOTAL", fmt.Sprintf("%d", B),
+				fmt.Sprintf("%d", totalBytesPH),
+				fmt.Sprintf("%.3f", phMed),
+				fmt.Sprintf("%.3f", phThr),
+				"",
+				backend,
+				"",
+			})
+		}
+
+		_ = w.Write([]string{})
+	}
+
+	fmt.Printf("OK! CSV saved at %s (streamed, readbuf=%d)\n", outCSV, readBuf)
+}
+
+// --- A/B comparison mode ---
+//
+// -compare old.csv loads two already-written CSVs (keyed by algo/file/B),
+// runs a two-sided Mann-Whitney U test on their raw "samples_ms" vectors,
+// and writes a diff CSV with a Holm-Bonferroni-corrected significance call.
+
+type csvSample struct {
+	algo    string
+	file    string
+	B       int
+	samples []float64
+}
+
+// loadCSVSamples reads a CSV produced by this program and recovers, for each
+// per-file row, the raw repetition times from the "samples_ms" column.
+// TOTAL rows and the blank separator lines between B values are skipped.
+func loadCSVSamples(path string) ([]csvSample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []csvSample
+	for i, rec := range records {
+		if i == 0 || len(rec) < 9 {
+			continue // header, or a blank B-value separator row
+		}
+		algo, file := rec[0], rec[1]
+		if algo == "" || file == "TOTAL" {
+			continue
+		}
+		B, err := strconv.Atoi(rec[2])
+		if err != nil {
+			continue
+		}
+		var samples []float64
+		for _, s := range strings.Split(rec[8], ";") {
+			if s == "" {
+				continue
+			}
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				continue
+			}
+			samples = append(samples, v)
+		}
+		out = append(out, csvSample{algo: algo, file: file, B: B, samples: samples})
+	}
+	return out, nil
+}
+
+// minReliableN is the per-sample size below which the normal approximation
+// used for z/p below is not considered valid; small samples (the harness
+// defaults to -n 5) need this flagged rather than silently trusted.
+const minReliableN = 20
+
+// mannWhitneyU runs a two-sided Mann-Whitney U test between two independent
+// samples, handling ties by averaging ranks, and returns U (for sample a),
+// the normal-approximation z-score, the two-sided p-value, and whether both
+// samples are large enough (n >= minReliableN) for that normal approximation
+// to be valid. Below minReliableN, p is still returned but should be treated
+// as indicative at best — callers should surface reliable=false rather than
+// act on p as if it were exact.
+func mannWhitneyU(a, b []float64) (U, z, p float64, reliable bool) {
+	type tagged struct {
+		v     float64
+		fromA bool
+	}
+	combined := make([]tagged, 0, len(a)+len(b))
+	for _, v := range a {
+		combined = append(combined, tagged{v, true})
+	}
+	for _, v := range b {
+		combined = append(combined, tagged{v, false})
+	}
+	sort.Slice(combined, func(i, j int) bool { return combined[i].v < combined[j].v })
+
+	ranks := make([]float64, len(combined))
+	for i := 0; i < len(combined); {
+		j := i
+		for j+1 < len(combined) && combined[j+1].v == combined[i].v {
+			j++
+		}
+		avgRank := float64(i+j)/2.0 + 1.0 // ranks are 1-indexed
+		for k := i; k <= j; k++ {
+			ranks[k] = avgRank
+		}
+		i = j + 1
+	}
+
+	var rankSumA float64
+	for i, c := range combined {
+		if c.fromA {
+			rankSumA += ranks[i]
+		}
+	}
+	nA, nB := float64(len(a)), float64(len(b))
+	U = rankSumA - nA*(nA+1)/2.0
+	reliable = len(a) >= minReliableN && len(b) >= minReliableN
+
+	meanU := nA * nB / 2.0
+	sigmaU := math.Sqrt(nA * nB * (nA + nB + 1) / 12.0)
+	if sigmaU == 0 {
+		return U, 0, 1, reliable
+	}
+	z = (U - meanU) / sigmaU
+	p = 2 * (1 - stdNormalCDF(math.Abs(z)))
+	if p > 1 {
+		p = 1
+	}
+	return U, z, p, reliable
+}
+
+func stdNormalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// holmBonferroni applies the Holm step-down procedure to a set of p-values
+// at family-wise significance level alpha, returning which indices reject
+// the null hypothesis.
+func holmBonferroni(pvals []float64, alpha float64) []bool {
+	m := len(pvals)
+	order := make([]int, m)
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return pvals[order[i]] < pvals[order[j]] })
+
+	sig := make([]bool, m)
+	for rank, idx := range order {
+		threshold := alpha / float64(m-rank)
+		if pvals[idx] > threshold {
+			break
+		}
+		sig[idx] = true
+	}
+	return sig
+}
+
+// runCompareMode loads oldPath and newPath, runs the U test on every
+// (algo,file,B) key present in both, and writes the diff CSV to diffPath.
+func runCompareMode(oldPath, newPath, diffPath string) error {
+	oldSamples, err := loadCSVSamples(oldPath)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", oldPath, err)
+	}
+	newSamples, err := loadCSVSamples(newPath)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", newPath, err)
+	}
+
+	type key struct {
+		algo, file string
+		B          int
+	}
+	oldByKey := make(map[key]csvSample, len(oldSamples))
+	for _, s := range oldSamples {
+		oldByKey[key{s.algo, s.file, s.B}] = s
+	}
+
+	type diffRow struct {
+		key            key
+		medOld, medNew float64
+		deltaPct, U, p float64
+		reliable       bool
+	}
+	var diffs []diffRow
+	var unreliable int
+	for _, nw := range newSamples {
+		k := key{nw.algo, nw.file, nw.B}
+		old, ok := oldByKey[k]
+		if !ok {
+			continue
+		}
+		U, _, p, reliable := mannWhitneyU(old.samples, nw.samples)
+		if !reliable {
+			unreliable++
+		}
+		medOld := medianFloat64(old.samples)
+		medNew := medianFloat64(nw.samples)
+		deltaPct := 0.0
+		if medOld != 0 {
+			deltaPct = (medNew - medOld) / medOld * 100.0
+		}
+		diffs = append(diffs, diffRow{k, medOld, medNew, deltaPct, U, p, reliable})
+	}
+	if unreliable > 0 {
+		fmt.Fprintf(os.Stderr, "warning: %d/%d comparisons have a sample with n < %d; their p-values use the normal approximation outside its valid range and are marked p_reliable=false\n", unreliable, len(diffs), minReliableN)
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].key.algo != diffs[j].key.algo {
+			return diffs[i].key.algo < diffs[j].key.algo
+		}
+		if diffs[i].key.file != diffs[j].key.file {
+			return diffs[i].key.file < diffs[j].key.file
+		}
+		return diffs[i].key.B < diffs[j].key.B
+	})
+
+	pvals := make([]float64, len(diffs))
+	for i, d := range diffs {
+		pvals[i] = d.p
+	}
+	sig := holmBonferroni(pvals, 0.05)
+
+	f, err := os.Create(diffPath)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", diffPath, err)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	defer w.Flush()
+	_ = w.Write([]string{"algo", "file", "B", "median_old", "median_new", "delta_pct", "U", "p_value", "p_reliable", "significant"})
+	for i, d := range diffs {
+		_ = w.Write([]string{
+			d.key.algo, d.key.file, strconv.Itoa(d.key.B),
+			fmt.Sprintf("%.3f", d.medOld),
+			fmt.Sprintf("%.3f", d.medNew),
+			fmt.Sprintf("%.2f", d.deltaPct),
+			fmt.Sprintf("%.3f", d.U),
+			fmt.Sprintf("%.4g", d.p),
+			strconv.FormatBool(d.reliable),
+			strconv.FormatBool(sig[i]),
+		})
+	}
+
+	fmt.Printf("OK! Comparison CSV saved at %s (%d rows)\n", diffPath, len(diffs))
+	return nil
+}
+
 // --- main ---
 
 func main() {
 	var (
-		outCSV    string
-		BsStr     string
-		Lbits     int
-		S         string
-		nRep      int
-		doConcat  bool
-		procs     int
-		algoSel   string
+		outCSV   string
+		BsStr    string
+		Lbits    int
+		S        string
+		nRep     int
+		doConcat bool
+		procs    int
+		algoSel  string
 
 		// profile output paths (empty = disabled)
-		cpuProfPath   string
-		memProfPath   string
-		blockProfPath string
-		mutexProfPath string
+		cpuProfPath    string
+		memProfPath    string
+		blockProfPath  string
+		mutexProfPath  string
 		gorProfPath    string
 		threadProfPath string
-		blockRate     int
-		mutexRate     int
+		blockRate      int
+		mutexRate      int
+		doLabels       bool
+		labelsPath     string
+		tracePath      string
+		traceRegion    bool
+		forceBackend   string
+		doStream       bool
+		readBufStr     string
+		compareOld     string
+		diffOut        string
 	)
 
 	flag.StringVar(&outCSV, "out", "snapshot_bench.csv", "output CSV file")
@@ -164,19 +740,71 @@ func main() {
 	flag.StringVar(&threadProfPath, "threadprof", "", "Thread-create profile output file (empty to disable)")
 	flag.IntVar(&blockRate, "blockrate", 0, "Block profile rate (0 = disable)")
 	flag.IntVar(&mutexRate, "mutexrate", 0, "Mutex profile rate (0 = disable)")
+	flag.BoolVar(&doLabels, "labels", false, "Tag every benchMedian run with pprof labels (algo/file/B/rep), usable with `go tool pprof -tagfocus` against -cpuprof's output, and write a plain-text index of the tuples used")
+	flag.StringVar(&labelsPath, "labelsout", "labels.txt.gz", "Label index output file (plain text, gzipped; only written when -labels is set)")
+	flag.StringVar(&tracePath, "traceout", "", "runtime/trace execution trace output file (empty to disable)")
+	flag.BoolVar(&traceRegion, "traceregion", false, "Wrap each benchMedian iteration in a trace.Region, with a nested per-shard region for each PH128 worker")
+	flag.StringVar(&forceBackend, "force-backend", "auto", "Keccak-p backend for PH128: auto|scalar|avx2|bmi2 (auto picks the fastest the CPU supports; avx2/bmi2 have no SIMD kernel yet and currently run scalar)")
+	flag.BoolVar(&doStream, "stream", false, "Stream each file through hash.Hash via io.Copy instead of reading it fully into memory (_all.data stays virtual)")
+	flag.StringVar(&readBufStr, "readbuf", "64K", "Read buffer size used by -stream (e.g. 64K, 1M)")
+	flag.StringVar(&compareOld, "compare", "", "Baseline CSV to compare -out against via a Mann-Whitney U test, instead of running benchmarks")
+	flag.StringVar(&diffOut, "diffout", "compare.csv", "Diff CSV output file (only written when -compare is set)")
 
 	flag.Parse()
 
+	if compareOld != "" {
+		if err := runCompareMode(compareOld, outCSV, diffOut); err != nil {
+			fmt.Fprintf(os.Stderr, "error comparing CSVs: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	algoSel = strings.ToLower(strings.TrimSpace(algoSel))
 	if algoSel != "both" && algoSel != "sha" && algoSel != "ph" {
 		fmt.Fprintf(os.Stderr, "invalid value for -algo: %s (use: both|sha|ph)\n", algoSel)
 		os.Exit(2)
 	}
 
+	forceBackend = strings.ToLower(strings.TrimSpace(forceBackend))
+	switch forceBackend {
+	case "auto":
+		// ph picks the fastest Keccak-p[1600,24] backend its internal/cpu
+		// feature detection finds (scalar/AVX2/BMI2); nothing to force here.
+	case "scalar", "avx2", "bmi2":
+		if err := ph.SetBackend(forceBackend); err != nil {
+			fmt.Fprintf(os.Stderr, "error selecting backend %q: %v\n", forceBackend, err)
+			os.Exit(2)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "invalid value for -force-backend: %s (use: auto|scalar|avx2|bmi2)\n", forceBackend)
+		os.Exit(2)
+	}
+
+	if active := ph.ActiveBackend(); active != "scalar" {
+		// hash/keccak.go doesn't vendor a real BMI2 or AVX2 kernel yet: both
+		// names currently dispatch to the scalar round function, so the CSV
+		// "backend" column records which path ran but cannot show a
+		// performance difference between them. Flag that loudly here rather
+		// than let it look like real per-backend data downstream.
+		fmt.Fprintf(os.Stderr, "warning: backend %q has no SIMD kernel in this build yet; it runs the scalar Keccak-p permutation, so PH128 timings will not differ from -force-backend=scalar\n", active)
+	}
+
 	if procs > 0 {
 		runtime.GOMAXPROCS(procs)
 	}
 
+	labelsEnabled = doLabels
+	if labelsEnabled {
+		defer func() {
+			if err := writeLabelIndex(labelsPath); err != nil {
+				fmt.Fprintf(os.Stderr, "error writing label index: %v\n", err)
+				return
+			}
+			fmt.Printf("Label index -> %s\n", labelsPath)
+		}()
+	}
+
 	if flag.NArg() == 0 {
 		fmt.Fprintln(os.Stderr, "usage: go run bench_snapshot.go [flags] <.data files...>")
 		flag.PrintDefaults()
@@ -206,6 +834,26 @@ func main() {
 		}()
 	}
 
+	// Execution trace
+	if tracePath != "" {
+		f, err := os.Create(tracePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error creating trace output: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Execution trace -> %s\n", tracePath)
+		if err := trace.Start(f); err != nil {
+			fmt.Fprintf(os.Stderr, "error trace.Start: %v\n", err)
+			f.Close()
+			os.Exit(1)
+		}
+		defer func() {
+			trace.Stop()
+			f.Close()
+		}()
+	}
+	traceRegionEnabled = traceRegion
+
 	// Heap / memory profile
 	if memProfPath != "" {
 		defer func() {
@@ -299,25 +947,51 @@ func main() {
 	}
 
 	// Thread-create profile
-    if threadProfPath != "" {
-        defer func() {
-            p := pprof.Lookup("threadcreate")
-            if p == nil {
+	if threadProfPath != "" {
+		defer func() {
+			p := pprof.Lookup("threadcreate")
+			if p == nil {
 				fmt.Fprintf(os.Stderr, "threadcreate profile not found\n")
-                return
-            }
-            f, err := os.Create(threadProfPath)
-            if err != nil {
+				return
+			}
+			f, err := os.Create(threadProfPath)
+			if err != nil {
 				fmt.Fprintf(os.Stderr, "error creating threadcreate profile: %v\n", err)
-                return
-            }
+				return
+			}
 			fmt.Printf("Threadcreate profile -> %s\n", threadProfPath)
 			if err := p.WriteTo(f, 0); err != nil {
 				fmt.Fprintf(os.Stderr, "error writing threadcreate profile: %v\n", err)
 			}
-            f.Close()
-        }()
-    }
+			f.Close()
+		}()
+	}
+
+	readBufSize, err := parseSize(readBufStr)
+	if err != nil || readBufSize <= 0 {
+		fmt.Fprintf(os.Stderr, "error in -readbuf: %v\n", err)
+		os.Exit(1)
+	}
+
+	if doStream {
+		paths := flag.Args()
+		var total int64
+		for _, p := range paths {
+			fi, err := os.Stat(p)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error stating %s: %v\n", p, err)
+				os.Exit(1)
+			}
+			total += fi.Size()
+		}
+		Bs, err := computeBs(BsStr, total)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error in -Bs: %v\n", err)
+			os.Exit(1)
+		}
+		runStreamMode(paths, outCSV, Bs, Lbits, S, nRep, algoSel, doConcat, readBufSize, forceBackend)
+		return
+	}
 
 	// Load .data files into memory
 	type inFile struct {
@@ -334,32 +1008,14 @@ func main() {
 		inputs = append(inputs, inFile{name: filepath.Base(p), data: b})
 	}
 
-	// If BsStr == "auto", choose B = len(X)/GOMAXPROCS for _all.data
-	// (this matches the earlier analysis where we slice the input per worker)
-	Bs := []int{}
-	if strings.ToLower(BsStr) == "auto" {
-		// se concat estiver habilitado, vamos usar o total de _all.data para calcular B
-		// If concatenation is enabled, use the total size of _all.data to compute B
-		var total int
-		for _, in := range inputs {
-			total += len(in.data)
-		}
-		g := runtime.GOMAXPROCS(0)
-		if g <= 0 {
-			g = 1
-		}
-		B := total / g
-		if B <= 0 {
-			B = 1 << 20 // fallback 1 MiB
-		}
-		Bs = []int{B}
-	} else {
-		var err error
-		Bs, err = parseBs(BsStr)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "error in -Bs: %v\n", err)
-			os.Exit(1)
-		}
+	var total int64
+	for _, in := range inputs {
+		total += int64(len(in.data))
+	}
+	Bs, err := computeBs(BsStr, total)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error in -Bs: %v\n", err)
+		os.Exit(1)
 	}
 
 	// _all.data (concatenate in memory) if requested
@@ -384,7 +1040,7 @@ func main() {
 	defer f.Close()
 	w := csv.NewWriter(f)
 	defer w.Flush()
-	_ = w.Write([]string{"algo", "file", "B_bytes", "bytes", "elapsed_ms_med", "throughput_mib_s", "sum_hex"})
+	_ = w.Write([]string{"algo", "file", "B_bytes", "bytes", "elapsed_ms_med", "throughput_mib_s", "sum_hex", "backend", "samples_ms"})
 
 	for _, B := range Bs {
 		var (
@@ -398,16 +1054,18 @@ func main() {
 
 			// --- SHA-256 ---
 			if algoSel == "both" || algoSel == "sha" {
-				r1 := benchMedian("SHA-256", B, in.name, in.data, nRep, func(b []byte) []byte {
+				r1 := benchMedian("SHA-256", B, in.name, in.data, nRep, func(_ context.Context, b []byte) []byte {
 					h := sha256.Sum256(b)
 					return h[:]
-				})
+				}, "")
 				_ = w.Write([]string{
 					r1.Algo, r1.File, fmt.Sprintf("%d", r1.Bbytes),
 					fmt.Sprintf("%d", r1.Bytes),
 					fmt.Sprintf("%.3f", r1.ElapsedMs),
 					fmt.Sprintf("%.3f", r1.Throughput),
 					r1.SumHex,
+					r1.Backend,
+					formatSamples(r1.Samples),
 				})
 				totalBytesSHA += r1.Bytes
 				elSHAms = append(elSHAms, r1.ElapsedMs)
@@ -415,15 +1073,20 @@ func main() {
 
 			// --- PH128 ---
 			if algoSel == "both" || algoSel == "ph" {
-				r2 := benchMedian("PH128", B, in.name, in.data, nRep, func(b []byte) []byte {
-					return ph.ParallelHash128Goroutines(b, B, Lbits, S)
-				})
+				r2 := benchMedian("PH128", B, in.name, in.data, nRep, func(ctx context.Context, b []byte) []byte {
+					// ParallelHash128GoroutinesContext annotates each shard worker
+					// with a trace region under ctx, so -traceregion's per-iteration
+					// region gets a per-shard timeline nested inside it.
+					return ph.ParallelHash128GoroutinesContext(ctx, b, B, Lbits, S)
+				}, ph.ActiveBackend())
 				_ = w.Write([]string{
 					r2.Algo, r2.File, fmt.Sprintf("%d", r2.Bbytes),
 					fmt.Sprintf("%d", r2.Bytes),
 					fmt.Sprintf("%.3f", r2.ElapsedMs),
 					fmt.Sprintf("%.3f", r2.Throughput),
 					r2.SumHex,
+					r2.Backend,
+					formatSamples(r2.Samples),
 				})
 				totalBytesPH += r2.Bytes
 				elPHms = append(elPHms, r2.ElapsedMs)
@@ -444,6 +1107,8 @@ func main() {
 				fmt.Sprintf("%.3f", shaMed),
 				fmt.Sprintf("%.3f", shaThr),
 				"",
+				"",
+				"",
 			})
 		}
 
@@ -461,6 +1126,8 @@ func main() {
 				fmt.Sprintf("%.3f", phMed),
 				fmt.Sprintf("%.3f", phThr),
 				"",
+				ph.ActiveBackend(),
+				"",
 			})
 		}
 
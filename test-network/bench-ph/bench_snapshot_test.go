@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// TestMannWhitneyUNoTies checks U against the textbook case where every
+// value in a is smaller than every value in b (U=0 for a).
+func TestMannWhitneyUNoTies(t *testing.T) {
+	a := []float64{1, 2, 3}
+	b := []float64{4, 5, 6}
+	U, _, _, reliable := mannWhitneyU(a, b)
+	if U != 0 {
+		t.Fatalf("U = %v, want 0", U)
+	}
+	if reliable {
+		t.Fatalf("reliable = true for n=3 samples, want false (below minReliableN=%d)", minReliableN)
+	}
+}
+
+// TestMannWhitneyUTies checks the tied-rank averaging: three equal values
+// (two from a, one from b) share rank (1+2+3)/3=2 instead of three separate
+// integer ranks.
+func TestMannWhitneyUTies(t *testing.T) {
+	a := []float64{1, 1, 2}
+	b := []float64{1, 3, 4}
+	// Sorted combined values: 1,1,1,2,3,4 -> tied rank 2 for the three 1s,
+	// then 4, 5, 6. rankSumA = 2+2+4 = 8, U = 8 - 3*4/2 = 2.
+	U, _, _, _ := mannWhitneyU(a, b)
+	if U != 2 {
+		t.Fatalf("U = %v, want 2", U)
+	}
+}
+
+// TestMannWhitneyUSymmetric checks that swapping a and b complements U
+// against nA*nB, since U(a,b) + U(b,a) == nA*nB.
+func TestMannWhitneyUSymmetric(t *testing.T) {
+	a := []float64{5, 6, 2, 9}
+	b := []float64{1, 7, 3}
+	Uab, _, _, _ := mannWhitneyU(a, b)
+	Uba, _, _, _ := mannWhitneyU(b, a)
+	if got, want := Uab+Uba, float64(len(a)*len(b)); got != want {
+		t.Fatalf("U(a,b)+U(b,a) = %v, want %v", got, want)
+	}
+}
+
+// TestMannWhitneyUReliableAboveThreshold checks that reliable flips to true
+// once both samples reach minReliableN.
+func TestMannWhitneyUReliableAboveThreshold(t *testing.T) {
+	a := make([]float64, minReliableN)
+	b := make([]float64, minReliableN)
+	for i := range a {
+		a[i] = float64(i)
+		b[i] = float64(i) + 0.5
+	}
+	_, _, _, reliable := mannWhitneyU(a, b)
+	if !reliable {
+		t.Fatalf("reliable = false for n=%d samples, want true", minReliableN)
+	}
+}
+
+// TestMannWhitneyUIdenticalSamples checks that two identical samples (U at
+// its midpoint, z=0) round-trip to p=1.
+func TestMannWhitneyUIdenticalSamples(t *testing.T) {
+	a := []float64{1, 2, 3, 4}
+	b := []float64{1, 2, 3, 4}
+	_, z, p, _ := mannWhitneyU(a, b)
+	if z != 0 {
+		t.Fatalf("z = %v, want 0", z)
+	}
+	if math.Abs(p-1) > 1e-9 {
+		t.Fatalf("p = %v, want 1", p)
+	}
+}
+
+// TestHolmBonferroniStepDown checks the step-down stop: once a sorted
+// p-value fails its threshold, every less-significant p-value after it is
+// also marked not significant, even if testing it in isolation would have
+// passed.
+func TestHolmBonferroniStepDown(t *testing.T) {
+	pvals := []float64{0.01, 0.02, 0.03, 0.04}
+	const alpha = 0.05
+	// thresholds in rank order: 0.05/4=0.0125, 0.05/3=0.01667, ...
+	// 0.01 <= 0.0125 passes; 0.02 > 0.01667 fails and stops the step-down.
+	got := holmBonferroni(pvals, alpha)
+	want := []bool{true, false, false, false}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sig[%d] = %v, want %v (got %v)", i, got[i], want[i], got)
+		}
+	}
+}
+
+// TestHolmBonferroniAllSignificant checks that every p-value is accepted
+// when all comfortably clear their step-down thresholds.
+func TestHolmBonferroniAllSignificant(t *testing.T) {
+	pvals := []float64{0.001, 0.002, 0.003, 0.004}
+	got := holmBonferroni(pvals, 0.05)
+	for i, s := range got {
+		if !s {
+			t.Fatalf("sig[%d] = false, want true (got %v)", i, got)
+		}
+	}
+}
+
+// TestHolmBonferroniUnordered checks that significance is attributed to the
+// original (unsorted) indices, not to the rank order used internally.
+func TestHolmBonferroniUnordered(t *testing.T) {
+	pvals := []float64{0.04, 0.01, 0.03, 0.02}
+	// Sorted order is indices 1(0.01), 3(0.02), 2(0.03), 0(0.04).
+	// 0.01 <= 0.0125 passes; 0.02 > 0.05/3=0.01667 fails and stops.
+	got := holmBonferroni(pvals, 0.05)
+	want := []bool{false, true, false, false}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sig[%d] = %v, want %v (got %v)", i, got[i], want[i], got)
+		}
+	}
+}
+
+// TestParseBsRejectsNonPositive checks that a parsed B of zero or negative
+// (e.g. from a "-Bs 0" typo) is rejected here with a usage error, instead of
+// reaching the ph package and panicking there ("hash: B must be positive").
+func TestParseBsRejectsNonPositive(t *testing.T) {
+	for _, list := range []string{"0", "1M,0", "-1"} {
+		if _, err := parseBs(list); err == nil {
+			t.Fatalf("parseBs(%q): expected error, got none", list)
+		}
+	}
+}